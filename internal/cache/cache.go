@@ -0,0 +1,79 @@
+// Package cache provides a pluggable response cache for idempotent
+// JSON-RPC reads, keyed by the method name and its canonicalized
+// parameters.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Cache stores raw JSON-RPC results keyed by Key. Implementations must be
+// safe for concurrent use.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Config selects and configures a Cache implementation.
+type Config struct {
+	Enabled bool         `yaml:"enabled"`
+	Backend string       `yaml:"backend"` // "memory" or "redis"
+	Memory  MemoryConfig `yaml:"memory"`
+	Redis   RedisConfig  `yaml:"redis"`
+
+	// MethodTTLs sets a per-method cache TTL, keyed by JSON-RPC method
+	// name. Methods without an entry use ImmutableTTL when the call is
+	// pinned to a specific block hash, or are not cached otherwise.
+	MethodTTLs map[string]time.Duration `yaml:"methodTTLs"`
+
+	// ImmutableTTL is used for calls pinned to an exact historical block
+	// (a block hash, or a block number other than latest/pending), whose
+	// result can never change.
+	ImmutableTTL time.Duration `yaml:"immutableTTL"`
+}
+
+// New builds the Cache implementation selected by config.Backend.
+func New(config Config) (Cache, error) {
+	switch config.Backend {
+	case "", "memory":
+		return NewMemoryCache(config.Memory), nil
+	case "redis":
+		return NewRedisCache(config.Redis)
+	default:
+		return nil, fmt.Errorf("cache: unknown backend %q", config.Backend)
+	}
+}
+
+// Key derives the cache key for a JSON-RPC call from its method and
+// params, as sha256(method + canonical(params)).
+func Key(method string, params json.RawMessage) (string, error) {
+	canonical, err := canonicalize(params)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalize re-encodes params through a generic interface{} round-trip
+// so that equivalent JSON (differing only in whitespace or map key order)
+// produces the same bytes.
+func canonicalize(params json.RawMessage) ([]byte, error) {
+	if len(params) == 0 {
+		return []byte("null"), nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(params, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
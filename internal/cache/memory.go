@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryConfig configures the in-memory LRU cache.
+type MemoryConfig struct {
+	// MaxItems caps the number of cached entries. Zero disables the cap.
+	MaxItems int `yaml:"maxItems"`
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryCache is an in-process LRU cache with a size cap and per-entry
+// TTL expiry.
+type MemoryCache struct {
+	maxItems int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// NewMemoryCache creates a MemoryCache per config.
+func NewMemoryCache(config MemoryConfig) *MemoryCache {
+	return &MemoryCache{
+		maxItems: config.MaxItems,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (c *MemoryCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := elem.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.removeLocked(elem)
+		return nil, false, nil
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.value, true, nil
+}
+
+func (c *MemoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*memoryEntry).value = value
+		elem.Value.(*memoryEntry).expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return nil
+	}
+
+	elem := c.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+	c.entries[key] = elem
+
+	if c.maxItems > 0 && c.order.Len() > c.maxItems {
+		c.removeLocked(c.order.Back())
+	}
+	return nil
+}
+
+// removeLocked evicts elem. Callers must hold c.mu.
+func (c *MemoryCache) removeLocked(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCacheGetSet(t *testing.T) {
+	c := NewMemoryCache(MemoryConfig{})
+	ctx := context.Background()
+
+	_, ok, err := c.Get(ctx, "missing")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, c.Set(ctx, "k", []byte("v"), time.Minute))
+
+	value, ok, err := c.Get(ctx, "k")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("v"), value)
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	c := NewMemoryCache(MemoryConfig{})
+	ctx := context.Background()
+
+	assert.Nil(t, c.Set(ctx, "k", []byte("v"), time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok, err := c.Get(ctx, "k")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewMemoryCache(MemoryConfig{MaxItems: 2})
+	ctx := context.Background()
+
+	assert.Nil(t, c.Set(ctx, "a", []byte("1"), 0))
+	assert.Nil(t, c.Set(ctx, "b", []byte("2"), 0))
+
+	// Touch "a" so "b" becomes the least-recently-used entry.
+	_, _, _ = c.Get(ctx, "a")
+
+	assert.Nil(t, c.Set(ctx, "c", []byte("3"), 0))
+
+	_, ok, _ := c.Get(ctx, "b")
+	assert.False(t, ok)
+
+	_, ok2, _ := c.Get(ctx, "a")
+	assert.True(t, ok2)
+}
@@ -0,0 +1,121 @@
+// Package jsonrpc provides minimal encode/decode types for JSON-RPC 2.0
+// requests and responses, along with batch helpers used by the proxy to
+// route and reassemble per-call traffic.
+package jsonrpc
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidRequest is returned when a payload is neither a single
+// JSON-RPC request object nor a batch array of them.
+var ErrInvalidRequest = errors.New("jsonrpc: invalid request")
+
+// Request is a single JSON-RPC 2.0 request object.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a single JSON-RPC 2.0 response object.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC 2.0 error object.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// IsEmptyResult reports whether r's result is the JSON null literal or an
+// empty array, the common shapes returned by lagging nodes for
+// eth_getTransactionReceipt/eth_getLogs.
+func (r Response) IsEmptyResult() bool {
+	trimmed := trimSpace(r.Result)
+	if len(trimmed) == 0 {
+		return true
+	}
+	switch string(trimmed) {
+	case "null", "[]":
+		return true
+	}
+	return false
+}
+
+func trimSpace(b json.RawMessage) json.RawMessage {
+	start, end := 0, len(b)
+	for start < end && isSpace(b[start]) {
+		start++
+	}
+	for end > start && isSpace(b[end-1]) {
+		end--
+	}
+	return b[start:end]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// ParseBatch decodes body as either a single JSON-RPC request or a batch
+// (array) of requests. isBatch reports which form was found so callers
+// can reassemble responses in the matching shape.
+func ParseBatch(body []byte) (requests []Request, isBatch bool, err error) {
+	trimmed := trimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, false, ErrInvalidRequest
+	}
+
+	if trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &requests); err != nil {
+			return nil, false, err
+		}
+		return requests, true, nil
+	}
+
+	var req Request
+	if err := json.Unmarshal(trimmed, &req); err != nil {
+		return nil, false, err
+	}
+
+	return []Request{req}, false, nil
+}
+
+// MarshalBatch re-encodes responses as a batch array if isBatch is true,
+// or as the single object otherwise, mirroring the shape ParseBatch
+// received.
+func MarshalBatch(responses []Response, isBatch bool) ([]byte, error) {
+	if !isBatch {
+		if len(responses) == 0 {
+			return nil, ErrInvalidRequest
+		}
+		return json.Marshal(responses[0])
+	}
+	return json.Marshal(responses)
+}
+
+// NewErrorResponse builds a Response carrying an error for the given
+// request, preserving its id so batch reassembly keeps request/response
+// pairs aligned.
+func NewErrorResponse(req Request, code int, message string) Response {
+	return Response{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Error: &Error{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package jsonrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBatchSingleRequest(t *testing.T) {
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+
+	requests, isBatch, err := ParseBatch(body)
+
+	assert.Nil(t, err)
+	assert.False(t, isBatch)
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "eth_chainId", requests[0].Method)
+}
+
+func TestParseBatchMultipleRequests(t *testing.T) {
+	body := []byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]},
+		{"jsonrpc":"2.0","id":2,"method":"eth_blockNumber","params":[]}
+	]`)
+
+	requests, isBatch, err := ParseBatch(body)
+
+	assert.Nil(t, err)
+	assert.True(t, isBatch)
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "eth_blockNumber", requests[1].Method)
+}
+
+func TestParseBatchInvalidRequest(t *testing.T) {
+	_, _, err := ParseBatch([]byte(``))
+
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestMarshalBatchPreservesShape(t *testing.T) {
+	responses := []Response{
+		{JSONRPC: "2.0", ID: []byte(`1`), Result: []byte(`"0x1"`)},
+	}
+
+	single, err := MarshalBatch(responses, false)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, string(single))
+
+	batch, err := MarshalBatch(responses, true)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":1,"result":"0x1"}]`, string(batch))
+}
+
+func TestResponseIsEmptyResult(t *testing.T) {
+	assert.True(t, Response{Result: []byte(`null`)}.IsEmptyResult())
+	assert.True(t, Response{Result: []byte(`[]`)}.IsEmptyResult())
+	assert.False(t, Response{Result: []byte(`"0x1"`)}.IsEmptyResult())
+}
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tiennampham23/rpc-gateway/internal/cache"
+)
+
+func TestHttpFailoverProxyCachesChainID(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	var hits int
+	fakeRPCServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer fakeRPCServer.Close()
+
+	rpcGatewayConfig := createConfig()
+	rpcGatewayConfig.Targets = []TargetConfig{
+		{Name: "Server1", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: fakeRPCServer.URL}}},
+	}
+	rpcGatewayConfig.Cache = cache.Config{
+		Enabled:    true,
+		Backend:    "memory",
+		MethodTTLs: map[string]time.Duration{"eth_chainId": time.Minute},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: rpcGatewayConfig.Targets,
+		Config:  rpcGatewayConfig.HealthChecks,
+	})
+	httpFailoverProxy := NewProxy(rpcGatewayConfig, healthcheckManager)
+
+	sendChainIDRequest := func() *httptest.ResponseRecorder {
+		requestBody := bytes.NewBufferString(`[{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}]`)
+		req, err := http.NewRequest("POST", "/", requestBody)
+		assert.Nil(t, err)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(httpFailoverProxy.ServeHTTP).ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := sendChainIDRequest()
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":1,"result":"0x1"}]`, first.Body.String())
+
+	second := sendChainIDRequest()
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":1,"result":"0x1"}]`, second.Body.String())
+
+	assert.Equal(t, 1, hits)
+}
+
+func TestHttpFailoverProxyCachesSingleRequestNotJustBatches(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	var hits int
+	fakeRPCServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer fakeRPCServer.Close()
+
+	rpcGatewayConfig := createConfig()
+	rpcGatewayConfig.Targets = []TargetConfig{
+		{Name: "Server1", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: fakeRPCServer.URL}}},
+	}
+	rpcGatewayConfig.Cache = cache.Config{
+		Enabled:    true,
+		Backend:    "memory",
+		MethodTTLs: map[string]time.Duration{"eth_chainId": time.Minute},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: rpcGatewayConfig.Targets,
+		Config:  rpcGatewayConfig.HealthChecks,
+	})
+	httpFailoverProxy := NewProxy(rpcGatewayConfig, healthcheckManager)
+
+	sendChainIDRequest := func() *httptest.ResponseRecorder {
+		// Not wrapped in an array: must still hit/populate the cache.
+		requestBody := bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+		req, err := http.NewRequest("POST", "/", requestBody)
+		assert.Nil(t, err)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(httpFailoverProxy.ServeHTTP).ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := sendChainIDRequest()
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, first.Body.String())
+
+	second := sendChainIDRequest()
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, second.Body.String())
+
+	assert.Equal(t, 1, hits)
+}
+
+func TestHttpFailoverProxyCachesBlockByNumberPinnedToHistoricalBlock(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	var hits int
+	fakeRPCServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x3039"}}`))
+	}))
+	defer fakeRPCServer.Close()
+
+	rpcGatewayConfig := createConfig()
+	rpcGatewayConfig.Targets = []TargetConfig{
+		{Name: "Server1", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: fakeRPCServer.URL}}},
+	}
+	rpcGatewayConfig.Cache = cache.Config{
+		Enabled:      true,
+		Backend:      "memory",
+		ImmutableTTL: time.Minute,
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: rpcGatewayConfig.Targets,
+		Config:  rpcGatewayConfig.HealthChecks,
+	})
+	httpFailoverProxy := NewProxy(rpcGatewayConfig, healthcheckManager)
+
+	sendGetBlockByNumberRequest := func() *httptest.ResponseRecorder {
+		// eth_getBlockByNumber's block tag is params[0], not the last
+		// element (params[1] is the includeTxs flag).
+		requestBody := bytes.NewBufferString(`[{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x3039",false]}]`)
+		req, err := http.NewRequest("POST", "/", requestBody)
+		assert.Nil(t, err)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(httpFailoverProxy.ServeHTTP).ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := sendGetBlockByNumberRequest()
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":1,"result":{"number":"0x3039"}}]`, first.Body.String())
+
+	second := sendGetBlockByNumberRequest()
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":1,"result":{"number":"0x3039"}}]`, second.Body.String())
+
+	assert.Equal(t, 1, hits)
+}
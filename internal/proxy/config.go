@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/tiennampham23/rpc-gateway/internal/cache"
+)
+
+// Config is the top-level configuration for the rpc-gateway proxy.
+type Config struct {
+	Proxy        ProxyConfig       `yaml:"proxy"`
+	HealthChecks HealthCheckConfig `yaml:"healthChecks"`
+	Targets      []TargetConfig    `yaml:"targets"`
+	Cache        cache.Config      `yaml:"cache"`
+}
+
+// ProxyConfig configures the behavior of the HTTP failover proxy itself,
+// as opposed to the targets it proxies to.
+type ProxyConfig struct {
+	UpstreamTimeout time.Duration `yaml:"upstreamTimeout"`
+
+	// MethodPolicy lets operators pin specific JSON-RPC methods (e.g.
+	// eth_getLogs, debug_traceTransaction) to a subset of targets, override
+	// the per-call timeout, or mark the method for retry-on-empty-result
+	// semantics. Methods without an entry fall back to the default
+	// failover behavior across all targets.
+	MethodPolicy map[string]MethodPolicyConfig `yaml:"methodPolicy"`
+
+	// TrustedProxies lists the CIDR ranges of upstream proxies/load
+	// balancers the gateway sits behind. Inbound X-Forwarded-* headers are
+	// only trusted (appended to, rather than overwritten) when the
+	// immediate peer's address falls within one of these ranges.
+	TrustedProxies []string `yaml:"trustedProxies"`
+
+	// Strategy selects how candidate targets are ordered before the
+	// failover walk: "priority" (default, config order), "round_robin",
+	// "weighted" (per-target TargetConfig.Weight), or "p2c-ewma"
+	// (power-of-two-choices on observed latency/error rate).
+	Strategy string `yaml:"strategy"`
+}
+
+// MethodPolicyConfig describes how a single JSON-RPC method should be
+// routed and retried.
+type MethodPolicyConfig struct {
+	// Targets restricts the method to the named (or tagged) targets. An
+	// empty list means all targets are eligible.
+	Targets []string `yaml:"targets"`
+
+	// Timeout overrides ProxyConfig.UpstreamTimeout for this method.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// RetryOnEmpty re-dispatches the call to the next eligible target when
+	// the result is `null` or an empty array, which is common for
+	// eth_getTransactionReceipt/eth_getLogs on a lagging node.
+	RetryOnEmpty bool `yaml:"retryOnEmpty"`
+}
+
+// HealthCheckConfig configures the passive and active healthcheck
+// behavior for all targets.
+type HealthCheckConfig struct {
+	Interval         time.Duration `yaml:"interval"`
+	Timeout          time.Duration `yaml:"timeout"`
+	FailureThreshold int           `yaml:"failureThreshold"`
+	SuccessThreshold int           `yaml:"successThreshold"`
+
+	// MaxBlockLag taints a target whose eth_blockNumber falls more than
+	// this many blocks behind the highest block height reported across
+	// all targets. Zero disables lag-based tainting.
+	MaxBlockLag uint64 `yaml:"maxBlockLag"`
+}
+
+// TargetConfig describes a single upstream RPC node.
+type TargetConfig struct {
+	Name       string                 `yaml:"name"`
+	Tags       []string               `yaml:"tags"`
+	Connection TargetConfigConnection `yaml:"connection"`
+
+	// Weight biases the "weighted" selection strategy towards this target.
+	// Targets with no weight (zero) default to a weight of 1.
+	Weight int `yaml:"weight"`
+}
+
+// TargetConfigConnection holds the supported transports for a target.
+// Exactly one of the nested connection configs is expected to be used for
+// a given request.
+type TargetConfigConnection struct {
+	HTTP TargetConnectionHTTP `yaml:"http"`
+	WS   TargetConnectionWS   `yaml:"ws"`
+}
+
+// TargetConnectionHTTP configures the HTTP(S) connection to a target.
+type TargetConnectionHTTP struct {
+	URL         string `yaml:"url"`
+	Compression bool   `yaml:"compression"`
+}
+
+// TargetConnectionWS configures the WebSocket (wss://) connection to a
+// target, used for subscription traffic (eth_subscribe/eth_unsubscribe).
+type TargetConnectionWS struct {
+	URL string `yaml:"url"`
+}
+
+// hasTag reports whether the target is labeled with the given tag, or
+// whether its Name matches it, so method policies can refer to targets
+// either by name or by tag.
+func (t TargetConfig) hasTag(tag string) bool {
+	if t.Name == tag {
+		return true
+	}
+	for _, tg := range t.Tags {
+		if tg == tag {
+			return true
+		}
+	}
+	return false
+}
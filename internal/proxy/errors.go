@@ -0,0 +1,8 @@
+package proxy
+
+import "errors"
+
+var (
+	errNoHealthyTargets = errors.New("proxy: no healthy targets available")
+	errUpstreamFailure  = errors.New("proxy: upstream returned a server error")
+)
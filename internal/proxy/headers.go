@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// hopByHopHeaders are stripped before forwarding a request, matching
+// net/http/httputil.ReverseProxy and RFC 7230 section 6.1.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailers",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// parseTrustedProxies parses a list of CIDR strings, silently skipping
+// any that fail to parse since they come from operator config rather than
+// user input.
+func parseTrustedProxies(cidrs []string) []*net.IPNet {
+	var trusted []*net.IPNet
+	for _, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		trusted = append(trusted, ipnet)
+	}
+	return trusted
+}
+
+// isTrustedPeer reports whether remoteAddr (as found in a request's
+// RemoteAddr) falls within one of the trusted CIDR ranges.
+func isTrustedPeer(remoteAddr string, trusted []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipnet := range trusted {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripHopByHopHeaders removes the standard hop-by-hop headers plus
+// anything the client listed in its own Connection header, as a reverse
+// proxy must not forward either to the next hop.
+func stripHopByHopHeaders(h http.Header) {
+	if connection := h.Get("Connection"); connection != "" {
+		for _, name := range strings.Split(connection, ",") {
+			h.Del(strings.TrimSpace(name))
+		}
+	}
+
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+}
+
+// setForwardedHeaders appends the client's address to X-Forwarded-For
+// (discarding any inbound chain unless peer is a trusted proxy) and sets
+// X-Forwarded-Proto, X-Forwarded-Host and Via for the next hop.
+func setForwardedHeaders(h http.Header, r *http.Request, trusted []*net.IPNet) {
+	clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		clientIP = r.RemoteAddr
+	}
+
+	if clientIP != "" {
+		if isTrustedPeer(r.RemoteAddr, trusted) {
+			if prior := h.Get("X-Forwarded-For"); prior != "" {
+				clientIP = prior + ", " + clientIP
+			}
+		}
+		h.Set("X-Forwarded-For", clientIP)
+	}
+
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+	h.Set("X-Forwarded-Proto", proto)
+	h.Set("X-Forwarded-Host", r.Host)
+
+	via := "1.1 rpc-gateway"
+	if prior := h.Get("Via"); prior != "" && isTrustedPeer(r.RemoteAddr, trusted) {
+		via = prior + ", " + via
+	}
+	h.Set("Via", via)
+}
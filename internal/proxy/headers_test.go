@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpFailoverProxyAppendsXForwardedForChain(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	var receivedXFF, receivedVia, receivedProto string
+	fakeRPCServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedXFF = r.Header.Get("X-Forwarded-For")
+		receivedVia = r.Header.Get("Via")
+		receivedProto = r.Header.Get("X-Forwarded-Proto")
+		w.Write([]byte("OK"))
+	}))
+	defer fakeRPCServer.Close()
+
+	rpcGatewayConfig := createConfig()
+	rpcGatewayConfig.Targets = []TargetConfig{
+		{Name: "Server1", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: fakeRPCServer.URL}}},
+	}
+	rpcGatewayConfig.Proxy.TrustedProxies = []string{"2.2.2.2/32"}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: rpcGatewayConfig.Targets,
+		Config:  rpcGatewayConfig.HealthChecks,
+	})
+	httpFailoverProxy := NewProxy(rpcGatewayConfig, healthcheckManager)
+
+	req, err := http.NewRequest("POST", "/", bytes.NewBufferString(`{}`))
+	assert.Nil(t, err)
+	req.RemoteAddr = "2.2.2.2:4321"
+	req.Header.Set("X-Forwarded-For", "3.3.3.3")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(httpFailoverProxy.ServeHTTP).ServeHTTP(rr, req)
+
+	assert.Equal(t, "3.3.3.3, 2.2.2.2", receivedXFF)
+	assert.Equal(t, "1.1 rpc-gateway", receivedVia)
+	assert.Equal(t, "http", receivedProto)
+}
+
+func TestHttpFailoverProxyOverwritesXForwardedForFromUntrustedPeer(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	var receivedXFF string
+	fakeRPCServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedXFF = r.Header.Get("X-Forwarded-For")
+		w.Write([]byte("OK"))
+	}))
+	defer fakeRPCServer.Close()
+
+	rpcGatewayConfig := createConfig()
+	rpcGatewayConfig.Targets = []TargetConfig{
+		{Name: "Server1", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: fakeRPCServer.URL}}},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: rpcGatewayConfig.Targets,
+		Config:  rpcGatewayConfig.HealthChecks,
+	})
+	httpFailoverProxy := NewProxy(rpcGatewayConfig, healthcheckManager)
+
+	req, err := http.NewRequest("POST", "/", bytes.NewBufferString(`{}`))
+	assert.Nil(t, err)
+	req.RemoteAddr = "9.9.9.9:4321"
+	req.Header.Set("X-Forwarded-For", "evil-spoofed-chain")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(httpFailoverProxy.ServeHTTP).ServeHTTP(rr, req)
+
+	assert.Equal(t, "9.9.9.9", receivedXFF)
+}
+
+func TestStripHopByHopHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Connection", "X-Custom-Hop")
+	h.Set("X-Custom-Hop", "drop-me")
+	h.Set("Keep-Alive", "timeout=5")
+	h.Set("Authorization", "Bearer keep-me")
+
+	stripHopByHopHeaders(h)
+
+	assert.Empty(t, h.Get("Connection"))
+	assert.Empty(t, h.Get("X-Custom-Hop"))
+	assert.Empty(t, h.Get("Keep-Alive"))
+	assert.Equal(t, "Bearer keep-me", h.Get("Authorization"))
+}
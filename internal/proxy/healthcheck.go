@@ -0,0 +1,268 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	targetHealthyGauge = promauto.With(prometheus.DefaultRegisterer).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rpc_gateway_target_healthy",
+		Help: "Whether the target is currently considered healthy (1) or tainted (0).",
+	}, []string{"target"})
+
+	targetBlockLagGauge = promauto.With(prometheus.DefaultRegisterer).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rpc_gateway_target_block_lag",
+		Help: "Number of blocks the target is behind the leader across all targets.",
+	}, []string{"target"})
+
+	targetLatencyGauge = promauto.With(prometheus.DefaultRegisterer).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rpc_gateway_target_latency_seconds",
+		Help: "Latency of the most recent active healthcheck probe.",
+	}, []string{"target"})
+)
+
+// HealthcheckManagerConfig configures a HealthcheckManager.
+type HealthcheckManagerConfig struct {
+	Targets []TargetConfig
+	Config  HealthCheckConfig
+}
+
+// targetState is the rolling health state tracked for a single target.
+type targetState struct {
+	healthy         bool
+	consecutiveOK   int
+	consecutiveFail int
+	blockLag        uint64
+	latency         time.Duration
+}
+
+// HealthcheckManager tracks the health of a fixed set of targets. Until
+// Start is called it reports every target as healthy, which lets tests
+// exercise HttpFailoverProxy's own retry behavior in isolation. Once
+// started, it actively probes every target on HealthCheckConfig.Interval,
+// tainting targets that fail too many consecutive probes or that fall
+// more than HealthCheckConfig.MaxBlockLag blocks behind the leader.
+type HealthcheckManager struct {
+	config HealthcheckManagerConfig
+	client *http.Client
+
+	mu     sync.RWMutex
+	states map[string]*targetState
+
+	cancel context.CancelFunc
+}
+
+// NewHealthcheckManager creates a HealthcheckManager for the given targets.
+func NewHealthcheckManager(config HealthcheckManagerConfig) *HealthcheckManager {
+	states := make(map[string]*targetState, len(config.Targets))
+	for _, target := range config.Targets {
+		states[target.Name] = &targetState{healthy: true}
+		targetHealthyGauge.WithLabelValues(target.Name).Set(1)
+	}
+
+	return &HealthcheckManager{
+		config: config,
+		client: &http.Client{Timeout: config.Config.Timeout},
+		states: states,
+	}
+}
+
+// Start begins actively probing every target on HealthCheckConfig.Interval
+// until the returned context is canceled or Stop is called.
+func (h *HealthcheckManager) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	h.cancel = cancel
+
+	ticker := time.NewTicker(h.config.Config.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.probeAll()
+			}
+		}
+	}()
+}
+
+// Stop halts the active probe loop started by Start.
+func (h *HealthcheckManager) Stop() {
+	if h.cancel != nil {
+		h.cancel()
+	}
+}
+
+// IsHealthy reports whether the named target is currently eligible to
+// receive traffic.
+func (h *HealthcheckManager) IsHealthy(name string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	state, ok := h.states[name]
+	if !ok {
+		return true
+	}
+	return state.healthy
+}
+
+// probeAll issues an eth_blockNumber probe to every target, then
+// evaluates taint/recovery against the resulting block heights.
+func (h *HealthcheckManager) probeAll() {
+	leader := uint64(0)
+	heights := make(map[string]uint64, len(h.config.Targets))
+
+	for _, target := range h.config.Targets {
+		height, err := h.probeOne(target)
+		if err == nil && height > leader {
+			leader = height
+		}
+		if err == nil {
+			heights[target.Name] = height
+		}
+
+		h.recordProbe(target.Name, err)
+	}
+
+	for name, height := range heights {
+		lag := uint64(0)
+		if leader > height {
+			lag = leader - height
+		}
+		targetBlockLagGauge.WithLabelValues(name).Set(float64(lag))
+		h.recordBlockLag(name, lag)
+	}
+}
+
+// probeOne issues eth_blockNumber against target and returns the reported
+// block height.
+func (h *HealthcheckManager) probeOne(target TargetConfig) (uint64, error) {
+	start := time.Now()
+
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`)
+	req, err := http.NewRequest(http.MethodPost, target.Connection.HTTP.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	latency := time.Since(start)
+	targetLatencyGauge.WithLabelValues(target.Name).Set(latency.Seconds())
+
+	h.mu.Lock()
+	if state, ok := h.states[target.Name]; ok {
+		state.latency = latency
+	}
+	h.mu.Unlock()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, errUpstreamFailure
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	var rpcResp struct {
+		Result string `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &rpcResp); err != nil {
+		return 0, err
+	}
+
+	return parseHexUint64(rpcResp.Result)
+}
+
+// recordProbe updates the consecutive success/failure counters for a
+// target and taints/recovers it against HealthCheckConfig's thresholds.
+func (h *HealthcheckManager) recordProbe(name string, probeErr error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.states[name]
+	if !ok {
+		return
+	}
+
+	if probeErr != nil {
+		state.consecutiveFail++
+		state.consecutiveOK = 0
+		if state.consecutiveFail >= h.config.Config.FailureThreshold {
+			state.healthy = false
+		}
+	} else {
+		state.consecutiveOK++
+		state.consecutiveFail = 0
+	}
+
+	h.maybeRecoverLocked(state)
+	h.setHealthyGaugeLocked(name, state)
+}
+
+// recordBlockLag taints a target whose reported height has fallen more
+// than MaxBlockLag blocks behind the leader, and re-evaluates recovery
+// now that its lag is known.
+func (h *HealthcheckManager) recordBlockLag(name string, lag uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.states[name]
+	if !ok {
+		return
+	}
+
+	state.blockLag = lag
+	if h.config.Config.MaxBlockLag > 0 && lag > h.config.Config.MaxBlockLag {
+		state.healthy = false
+	}
+
+	h.maybeRecoverLocked(state)
+	h.setHealthyGaugeLocked(name, state)
+}
+
+// maybeRecoverLocked un-taints a target once it has accumulated enough
+// consecutive successes and has caught back up within MaxBlockLag. Callers
+// must hold h.mu.
+func (h *HealthcheckManager) maybeRecoverLocked(state *targetState) {
+	if state.healthy {
+		return
+	}
+	if state.consecutiveOK < h.config.Config.SuccessThreshold {
+		return
+	}
+	if h.config.Config.MaxBlockLag > 0 && state.blockLag > h.config.Config.MaxBlockLag {
+		return
+	}
+	state.healthy = true
+}
+
+func (h *HealthcheckManager) setHealthyGaugeLocked(name string, state *targetState) {
+	value := 0.0
+	if state.healthy {
+		value = 1.0
+	}
+	targetHealthyGauge.WithLabelValues(name).Set(value)
+}
+
+func parseHexUint64(hex string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimPrefix(hex, "0x"), 16, 64)
+}
@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func blockNumberServer(t *testing.T, hex func() string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + hex() + `"}`))
+	}))
+}
+
+func TestHealthcheckManagerTaintsLaggingTarget(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	leader := blockNumberServer(t, func() string { return "0x64" })
+	defer leader.Close()
+
+	laggard := blockNumberServer(t, func() string { return "0x32" })
+	defer laggard.Close()
+
+	config := HealthCheckConfig{
+		Interval:         10 * time.Millisecond,
+		Timeout:          time.Second,
+		FailureThreshold: 3,
+		SuccessThreshold: 2,
+		MaxBlockLag:      5,
+	}
+	targets := []TargetConfig{
+		{Name: "leader", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: leader.URL}}},
+		{Name: "laggard", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: laggard.URL}}},
+	}
+
+	manager := NewHealthcheckManager(HealthcheckManagerConfig{Targets: targets, Config: config})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	manager.Start(ctx)
+	defer manager.Stop()
+
+	assert.Eventually(t, func() bool {
+		return manager.IsHealthy("leader") && !manager.IsHealthy("laggard")
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestHealthcheckManagerRecoversAfterCatchingUp(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	height := "0x32"
+	target := blockNumberServer(t, func() string { return height })
+	defer target.Close()
+
+	config := HealthCheckConfig{
+		Interval:         10 * time.Millisecond,
+		Timeout:          time.Second,
+		FailureThreshold: 3,
+		SuccessThreshold: 2,
+		MaxBlockLag:      5,
+	}
+	targets := []TargetConfig{
+		{Name: "only", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: target.URL}}},
+	}
+
+	manager := NewHealthcheckManager(HealthcheckManagerConfig{Targets: targets, Config: config})
+	manager.recordBlockLag("only", 100)
+	assert.False(t, manager.IsHealthy("only"))
+
+	manager.recordProbe("only", nil)
+	manager.recordProbe("only", nil)
+	manager.recordBlockLag("only", 0)
+
+	assert.True(t, manager.IsHealthy("only"))
+}
+
+func TestHttpFailoverProxySkipsHealthcheckTaintedTarget(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	var taintedHit bool
+	taintedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		taintedHit = true
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"should-not-be-used"}`))
+	}))
+	defer taintedServer.Close()
+
+	healthyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer healthyServer.Close()
+
+	rpcGatewayConfig := createConfig()
+	rpcGatewayConfig.Targets = []TargetConfig{
+		{Name: "tainted", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: taintedServer.URL}}},
+		{Name: "healthy", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: healthyServer.URL}}},
+	}
+	rpcGatewayConfig.HealthChecks = HealthCheckConfig{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: rpcGatewayConfig.Targets,
+		Config:  rpcGatewayConfig.HealthChecks,
+	})
+	// Taint "tainted" directly, the same way TestHealthcheckManagerTaintsLaggingTarget
+	// does, rather than starting the active probe loop.
+	healthcheckManager.recordProbe("tainted", errUpstreamFailure)
+	assert.False(t, healthcheckManager.IsHealthy("tainted"))
+
+	httpFailoverProxy := NewProxy(rpcGatewayConfig, healthcheckManager)
+
+	requestBody := bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+	req, err := http.NewRequest("POST", "/", requestBody)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(httpFailoverProxy.ServeHTTP)
+	handler.ServeHTTP(rr, req)
+
+	assert.False(t, taintedHit, "proxy must not route to a target the healthcheck manager has tainted")
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, rr.Body.String())
+}
@@ -0,0 +1,324 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/tiennampham23/rpc-gateway/internal/cache"
+)
+
+func TestHttpFailoverProxyBatchPreservesRequestIDOrder(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	fakeRPCServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int    `json:"id"`
+			Method string `json:"method"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &req)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":` + strconv.Itoa(req.ID) + `,"result":"0x` + req.Method + `"}`))
+	}))
+	defer fakeRPCServer.Close()
+
+	rpcGatewayConfig := createConfig()
+	rpcGatewayConfig.Targets = []TargetConfig{
+		{
+			Name: "Server1",
+			Connection: TargetConfigConnection{
+				HTTP: TargetConnectionHTTP{URL: fakeRPCServer.URL},
+			},
+		},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: rpcGatewayConfig.Targets,
+		Config:  rpcGatewayConfig.HealthChecks,
+	})
+	httpFailoverProxy := NewProxy(rpcGatewayConfig, healthcheckManager)
+
+	requestBody := bytes.NewBufferString(`[
+		{"jsonrpc":"2.0","id":1,"method":"aaa","params":[]},
+		{"jsonrpc":"2.0","id":2,"method":"bbb","params":[]}
+	]`)
+	req, err := http.NewRequest("POST", "/", requestBody)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(httpFailoverProxy.ServeHTTP)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[
+		{"jsonrpc":"2.0","id":1,"result":"0xaaa"},
+		{"jsonrpc":"2.0","id":2,"result":"0xbbb"}
+	]`, rr.Body.String())
+}
+
+func TestHttpFailoverProxyBatchPerCallFailover(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	fakeRPC1Server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Bad Request", http.StatusInternalServerError)
+	}))
+	defer fakeRPC1Server.Close()
+
+	fakeRPC2Server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer fakeRPC2Server.Close()
+
+	rpcGatewayConfig := createConfig()
+	rpcGatewayConfig.Targets = []TargetConfig{
+		{
+			Name:       "Server1",
+			Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: fakeRPC1Server.URL}},
+		},
+		{
+			Name:       "Server2",
+			Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: fakeRPC2Server.URL}},
+		},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: rpcGatewayConfig.Targets,
+		Config:  rpcGatewayConfig.HealthChecks,
+	})
+	httpFailoverProxy := NewProxy(rpcGatewayConfig, healthcheckManager)
+
+	requestBody := bytes.NewBufferString(`[{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}]`)
+	req, err := http.NewRequest("POST", "/", requestBody)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(httpFailoverProxy.ServeHTTP)
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":1,"result":"0x1"}]`, rr.Body.String())
+}
+
+func TestHttpFailoverProxySingleRequestHonorsMethodPolicy(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	var pinnedServerHit bool
+	pinnedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pinnedServerHit = true
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer pinnedServer.Close()
+
+	otherServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"should-not-be-used"}`))
+	}))
+	defer otherServer.Close()
+
+	rpcGatewayConfig := createConfig()
+	rpcGatewayConfig.Targets = []TargetConfig{
+		{Name: "archive", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: pinnedServer.URL}}},
+		{Name: "default", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: otherServer.URL}}},
+	}
+	rpcGatewayConfig.Proxy.MethodPolicy = map[string]MethodPolicyConfig{
+		"debug_traceTransaction": {Targets: []string{"archive"}},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: rpcGatewayConfig.Targets,
+		Config:  rpcGatewayConfig.HealthChecks,
+	})
+	httpFailoverProxy := NewProxy(rpcGatewayConfig, healthcheckManager)
+
+	// A plain (non-array) JSON-RPC request, not wrapped in a batch, must
+	// still be pinned by MethodPolicy rather than walking targets in
+	// config order.
+	requestBody := bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"debug_traceTransaction","params":[]}`)
+	req, err := http.NewRequest("POST", "/", requestBody)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(httpFailoverProxy.ServeHTTP)
+	handler.ServeHTTP(rr, req)
+
+	assert.True(t, pinnedServerHit)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`, rr.Body.String())
+}
+
+func TestHttpFailoverProxyMethodPolicyPinsTarget(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	var pinnedServerHit bool
+	pinnedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pinnedServerHit = true
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer pinnedServer.Close()
+
+	otherServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"should-not-be-used"}`))
+	}))
+	defer otherServer.Close()
+
+	rpcGatewayConfig := createConfig()
+	rpcGatewayConfig.Targets = []TargetConfig{
+		{Name: "archive", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: pinnedServer.URL}}},
+		{Name: "default", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: otherServer.URL}}},
+	}
+	rpcGatewayConfig.Proxy.MethodPolicy = map[string]MethodPolicyConfig{
+		"debug_traceTransaction": {Targets: []string{"archive"}},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: rpcGatewayConfig.Targets,
+		Config:  rpcGatewayConfig.HealthChecks,
+	})
+	httpFailoverProxy := NewProxy(rpcGatewayConfig, healthcheckManager)
+
+	requestBody := bytes.NewBufferString(`[{"jsonrpc":"2.0","id":1,"method":"debug_traceTransaction","params":[]}]`)
+	req, err := http.NewRequest("POST", "/", requestBody)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(httpFailoverProxy.ServeHTTP)
+	handler.ServeHTTP(rr, req)
+
+	assert.True(t, pinnedServerHit)
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","id":1,"result":"0x1"}]`, rr.Body.String())
+}
+
+func TestHttpFailoverProxyRetryOnEmptyMovesToNextTarget(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	laggingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`))
+	}))
+	defer laggingServer.Close()
+
+	caughtUpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"blockHash":"0x1"}}`))
+	}))
+	defer caughtUpServer.Close()
+
+	rpcGatewayConfig := createConfig()
+	rpcGatewayConfig.Targets = []TargetConfig{
+		{Name: "lagging", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: laggingServer.URL}}},
+		{Name: "caught-up", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: caughtUpServer.URL}}},
+	}
+	rpcGatewayConfig.Proxy.MethodPolicy = map[string]MethodPolicyConfig{
+		"eth_getTransactionReceipt": {RetryOnEmpty: true},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: rpcGatewayConfig.Targets,
+		Config:  rpcGatewayConfig.HealthChecks,
+	})
+	httpFailoverProxy := NewProxy(rpcGatewayConfig, healthcheckManager)
+
+	requestBody := bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionReceipt","params":["0xabc"]}`)
+	req, err := http.NewRequest("POST", "/", requestBody)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(httpFailoverProxy.ServeHTTP)
+	handler.ServeHTTP(rr, req)
+
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{"blockHash":"0x1"}}`, rr.Body.String())
+}
+
+func TestHttpFailoverProxyRetryOnEmptyReturnsRealResultWhenAllTargetsEmpty(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	emptyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`))
+	}))
+	defer emptyServer.Close()
+
+	rpcGatewayConfig := createConfig()
+	rpcGatewayConfig.Targets = []TargetConfig{
+		{Name: "Server1", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: emptyServer.URL}}},
+	}
+	rpcGatewayConfig.Proxy.MethodPolicy = map[string]MethodPolicyConfig{
+		"eth_getTransactionReceipt": {RetryOnEmpty: true},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: rpcGatewayConfig.Targets,
+		Config:  rpcGatewayConfig.HealthChecks,
+	})
+	httpFailoverProxy := NewProxy(rpcGatewayConfig, healthcheckManager)
+
+	// Every eligible target genuinely has no receipt for this tx yet; the
+	// real `result: null` must be returned, not a synthesized error.
+	requestBody := bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionReceipt","params":["0xabc"]}`)
+	req, err := http.NewRequest("POST", "/", requestBody)
+	assert.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(httpFailoverProxy.ServeHTTP)
+	handler.ServeHTTP(rr, req)
+
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":null}`, rr.Body.String())
+}
+
+func TestHttpFailoverProxyRetryOnEmptyExhaustionIsNotCached(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	var hits int
+	emptyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`))
+	}))
+	defer emptyServer.Close()
+
+	rpcGatewayConfig := createConfig()
+	rpcGatewayConfig.Targets = []TargetConfig{
+		{Name: "Server1", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: emptyServer.URL}}},
+	}
+	rpcGatewayConfig.Proxy.MethodPolicy = map[string]MethodPolicyConfig{
+		"eth_getTransactionReceipt": {RetryOnEmpty: true},
+	}
+	rpcGatewayConfig.Cache = cache.Config{
+		Enabled: true,
+		Backend: "memory",
+		MethodTTLs: map[string]time.Duration{
+			"eth_getTransactionReceipt": time.Minute,
+		},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: rpcGatewayConfig.Targets,
+		Config:  rpcGatewayConfig.HealthChecks,
+	})
+	httpFailoverProxy := NewProxy(rpcGatewayConfig, healthcheckManager)
+
+	requestBody := func() *bytes.Buffer {
+		return bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"eth_getTransactionReceipt","params":["0xabc"]}`)
+	}
+	handler := http.HandlerFunc(httpFailoverProxy.ServeHTTP)
+
+	req, err := http.NewRequest("POST", "/", requestBody())
+	assert.Nil(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":null}`, rr.Body.String())
+
+	// A RetryOnEmpty-exhaustion fallback must never be cached: caching it
+	// would keep serving a stale null for the configured TTL even after a
+	// target catches up and has a real receipt, defeating the whole point
+	// of RetryOnEmpty.
+	req, err = http.NewRequest("POST", "/", requestBody())
+	assert.Nil(t, err)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":null}`, rr.Body.String())
+
+	assert.Equal(t, 2, hits, "second request must hit the target again instead of being served from cache")
+}
@@ -0,0 +1,400 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/tiennampham23/rpc-gateway/internal/cache"
+	"github.com/tiennampham23/rpc-gateway/internal/jsonrpc"
+)
+
+var cacheHitsTotal = promauto.With(prometheus.DefaultRegisterer).NewCounterVec(prometheus.CounterOpts{
+	Name: "rpc_gateway_cache_hits_total",
+	Help: "Number of JSON-RPC calls served from cache without contacting a target.",
+}, []string{"method"})
+
+// Proxy is an HTTP reverse proxy that fails over JSON-RPC traffic across a
+// fixed, ordered list of targets, routing each call in a batch
+// independently according to ProxyConfig.MethodPolicy.
+type Proxy struct {
+	config         Config
+	healthcheck    *HealthcheckManager
+	client         *http.Client
+	cache          cache.Cache
+	trustedProxies []*net.IPNet
+	selector       Selector
+}
+
+// NewProxy creates a Proxy for the given config, consulting healthcheck
+// for target availability during failover.
+func NewProxy(config Config, healthcheck *HealthcheckManager) *Proxy {
+	p := &Proxy{
+		config:         config,
+		healthcheck:    healthcheck,
+		client:         &http.Client{},
+		trustedProxies: parseTrustedProxies(config.Proxy.TrustedProxies),
+		selector:       NewSelector(config.Proxy.Strategy),
+	}
+
+	if config.Cache.Enabled {
+		if c, err := cache.New(config.Cache); err == nil {
+			p.cache = c
+		}
+	}
+
+	return p
+}
+
+// ServeHTTP decompresses the request body if necessary, splits it into
+// one or more JSON-RPC calls, routes each independently through dispatch
+// (applying MethodPolicy/caching per call, whether the body was a single
+// request or a batch), and reassembles the responses preserving request
+// id order.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	wasGzipped := r.Header.Get("Content-Encoding") == "gzip"
+	plainBody := body
+	if wasGzipped {
+		plainBody, err = decompress(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	requests, isBatch, err := jsonrpc.ParseBatch(plainBody)
+	if err != nil || (!isBatch && requests[0].Method == "") {
+		// Not JSON-RPC we can understand, or a single object with no
+		// "method" (not a real JSON-RPC call). Preserve the historical
+		// behavior of forwarding the raw body untouched.
+		p.forwardRaw(w, r, body, wasGzipped)
+		return
+	}
+
+	responses := make([]jsonrpc.Response, len(requests))
+	for i, req := range requests {
+		responses[i] = p.dispatch(r, req)
+	}
+
+	out, err := jsonrpc.MarshalBatch(responses, isBatch)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(out)
+}
+
+// forwardRaw sends body (re-compressing per target as needed) through the
+// failover chain and copies the first successful response back to w.
+// This preserves exact byte-for-byte forwarding for requests that are not
+// a JSON-RPC batch.
+func (p *Proxy) forwardRaw(w http.ResponseWriter, r *http.Request, body []byte, wasGzipped bool) {
+	targets := p.eligibleTargets("")
+
+	var lastErr error
+	for _, target := range targets {
+		if !p.healthcheck.IsHealthy(target.Name) {
+			continue
+		}
+
+		outBody, headers, err := p.prepareHeaders(r, body, wasGzipped, target.Connection.HTTP.Compression)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		start := time.Now()
+		resp, err := p.doRequest(target, outBody, headers, p.config.Proxy.UpstreamTimeout)
+		p.selector.Observe(target.Name, time.Since(start), err)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		copyResponse(w, resp)
+		return
+	}
+
+	if lastErr == nil {
+		lastErr = errNoHealthyTargets
+	}
+	http.Error(w, lastErr.Error(), http.StatusBadGateway)
+}
+
+// dispatch routes a single JSON-RPC call across the eligible targets for
+// its method, applying the method's configured timeout and
+// retry-on-empty-result policy.
+func (p *Proxy) dispatch(r *http.Request, req jsonrpc.Request) (result jsonrpc.Response) {
+	policy, hasPolicy := p.config.Proxy.MethodPolicy[req.Method]
+
+	timeout := p.config.Proxy.UpstreamTimeout
+	if hasPolicy && policy.Timeout > 0 {
+		timeout = policy.Timeout
+	}
+
+	retriedOnEmpty := false
+
+	if ttl, cacheable := p.cacheTTL(req.Method, req.Params); p.cache != nil && cacheable {
+		cacheKey, err := cache.Key(req.Method, req.Params)
+		if err == nil {
+			if cached, hit, err := p.cache.Get(r.Context(), cacheKey); err == nil && hit {
+				cacheHitsTotal.WithLabelValues(req.Method).Inc()
+				return jsonrpc.Response{JSONRPC: "2.0", ID: req.ID, Result: cached}
+			}
+
+			defer func() {
+				// A RetryOnEmpty exhaustion fallback means no target had a
+				// better answer *yet* - caching it would mask a real result
+				// that shows up moments later, defeating the policy.
+				if result.Error == nil && !retriedOnEmpty {
+					p.cache.Set(r.Context(), cacheKey, result.Result, ttl)
+				}
+			}()
+		}
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return jsonrpc.NewErrorResponse(req, -32600, err.Error())
+	}
+
+	targets := p.eligibleTargets(req.Method)
+
+	var lastErr error
+	var lastEmptyResp jsonrpc.Response
+	haveLastEmptyResp := false
+	for _, target := range targets {
+		if !p.healthcheck.IsHealthy(target.Name) {
+			continue
+		}
+
+		outBody, headers, err := p.prepareHeaders(r, payload, false, target.Connection.HTTP.Compression)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		start := time.Now()
+		resp, err := p.doRequest(target, outBody, headers, timeout)
+		p.selector.Observe(target.Name, time.Since(start), err)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		respBody, err := readBody(resp)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var rpcResp jsonrpc.Response
+		if err := json.Unmarshal(respBody, &rpcResp); err != nil {
+			lastErr = err
+			continue
+		}
+		rpcResp.ID = req.ID
+
+		if hasPolicy && policy.RetryOnEmpty && rpcResp.IsEmptyResult() {
+			lastEmptyResp = rpcResp
+			haveLastEmptyResp = true
+			continue
+		}
+
+		return rpcResp
+	}
+
+	// Every eligible target legitimately returned an empty result (as
+	// opposed to erroring) — that's still a real response, not a reason
+	// to synthesize an error.
+	if haveLastEmptyResp {
+		retriedOnEmpty = true
+		return lastEmptyResp
+	}
+
+	if lastErr == nil {
+		lastErr = errNoHealthyTargets
+	}
+	return jsonrpc.NewErrorResponse(req, -32000, lastErr.Error())
+}
+
+// cacheableMethods lists JSON-RPC methods whose result is safe to cache
+// once pinned to an immutable point in the chain's history.
+var cacheableMethods = map[string]bool{
+	"eth_chainId":               true,
+	"net_version":               true,
+	"eth_getBlockByNumber":      true,
+	"eth_getTransactionReceipt": true,
+	"eth_call":                  true,
+}
+
+// cacheTTL decides whether req is safe to cache and, if so, for how long.
+// eth_chainId/net_version use their configured MethodTTLs entry (or are
+// skipped if unset). eth_getBlockByNumber and eth_call are only cached
+// when pinned to a specific historical block (a numeric tag or an exact
+// block hash), in which case the result can never change and
+// Cache.ImmutableTTL applies. eth_getTransactionReceipt uses its
+// configured TTL, since a reorg can still change recent receipts.
+func (p *Proxy) cacheTTL(method string, params json.RawMessage) (time.Duration, bool) {
+	if !cacheableMethods[method] {
+		return 0, false
+	}
+
+	switch method {
+	case "eth_getBlockByNumber", "eth_call":
+		if !isPinnedToImmutableBlock(method, params) {
+			return 0, false
+		}
+		return p.config.Cache.ImmutableTTL, true
+	default:
+		ttl, ok := p.config.Cache.MethodTTLs[method]
+		return ttl, ok
+	}
+}
+
+// isPinnedToImmutableBlock reports whether method's block tag argument is
+// a tag other than "latest"/"pending"/"earliest" (a numeric tag) or an
+// exact 32-byte block hash, either of which can never change. The block
+// tag's position in params depends on the method: eth_call takes
+// [callObject, blockTag], so the tag is the last element, while
+// eth_getBlockByNumber takes [blockTag, includeTxs], so the tag is the
+// first element.
+func isPinnedToImmutableBlock(method string, params json.RawMessage) bool {
+	var args []json.RawMessage
+	if err := json.Unmarshal(params, &args); err != nil || len(args) == 0 {
+		return false
+	}
+
+	tagArg := args[len(args)-1]
+	if method == "eth_getBlockByNumber" {
+		tagArg = args[0]
+	}
+
+	var tag string
+	if err := json.Unmarshal(tagArg, &tag); err != nil {
+		return false
+	}
+
+	switch tag {
+	case "latest", "pending", "earliest":
+		return false
+	}
+
+	return len(tag) > 2 && tag[:2] == "0x"
+}
+
+// eligibleTargets returns the candidate targets for method, restricted to
+// MethodPolicy.Targets when one is configured and ordered by the
+// configured selection Strategy.
+func (p *Proxy) eligibleTargets(method string) []TargetConfig {
+	policy, ok := p.config.Proxy.MethodPolicy[method]
+	if !ok || len(policy.Targets) == 0 {
+		return p.selector.Order(p.config.Targets)
+	}
+
+	var eligible []TargetConfig
+	for _, target := range p.config.Targets {
+		for _, name := range policy.Targets {
+			if target.hasTag(name) {
+				eligible = append(eligible, target)
+				break
+			}
+		}
+	}
+	return p.selector.Order(eligible)
+}
+
+func (p *Proxy) doRequest(target TargetConfig, body []byte, headers http.Header, timeout time.Duration) (*http.Response, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.Connection.HTTP.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = headers
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		resp.Body.Close()
+		return nil, errUpstreamFailure
+	}
+	return resp, nil
+}
+
+// prepareHeaders decompresses body when the caller sent it gzipped but
+// the target does not accept compressed bodies, re-calculates the
+// headers (Content-Encoding/Content-Length) to match, strips hop-by-hop
+// headers, and sets the X-Forwarded-*/Via headers for the next hop.
+func (p *Proxy) prepareHeaders(r *http.Request, body []byte, wasGzipped, targetAcceptsGzip bool) ([]byte, http.Header, error) {
+	headers := r.Header.Clone()
+	stripHopByHopHeaders(headers)
+	setForwardedHeaders(headers, r, p.trustedProxies)
+
+	if wasGzipped && !targetAcceptsGzip {
+		plain, err := decompress(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		headers.Del("Content-Encoding")
+		headers.Set("Content-Length", strconv.Itoa(len(plain)))
+		return plain, headers, nil
+	}
+
+	headers.Set("Content-Length", strconv.Itoa(len(body)))
+	return body, headers, nil
+}
+
+func decompress(body []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
+
+func readBody(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func copyResponse(w http.ResponseWriter, resp *http.Response) {
+	body, err := readBody(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(body)
+}
@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Selector orders a set of candidate targets for a single call, and
+// learns from how each call turns out so later calls can be routed
+// better. Proxy walks the returned order applying its existing failover
+// retry logic, so a Selector only changes which target is tried first
+// (and next), never whether failover happens.
+type Selector interface {
+	Order(targets []TargetConfig) []TargetConfig
+	Observe(target string, latency time.Duration, err error)
+}
+
+// NewSelector builds the Selector named by strategy, defaulting to the
+// current config-order behavior for an empty or unrecognized value.
+func NewSelector(strategy string) Selector {
+	switch strategy {
+	case "round_robin":
+		return &roundRobinSelector{}
+	case "weighted":
+		return &weightedSelector{}
+	case "p2c-ewma":
+		return newP2CEWMASelector()
+	default:
+		return prioritySelector{}
+	}
+}
+
+// prioritySelector is the original behavior: targets are tried in the
+// order they appear in config.
+type prioritySelector struct{}
+
+func (prioritySelector) Order(targets []TargetConfig) []TargetConfig { return targets }
+func (prioritySelector) Observe(string, time.Duration, error)        {}
+
+// roundRobinSelector rotates the starting point on every call so load
+// spreads evenly across targets, while still falling over to the rest of
+// the list in order from there.
+type roundRobinSelector struct {
+	counter uint64
+}
+
+func (s *roundRobinSelector) Order(targets []TargetConfig) []TargetConfig {
+	if len(targets) == 0 {
+		return targets
+	}
+
+	start := int(atomic.AddUint64(&s.counter, 1) % uint64(len(targets)))
+
+	ordered := make([]TargetConfig, len(targets))
+	for i := range targets {
+		ordered[i] = targets[(start+i)%len(targets)]
+	}
+	return ordered
+}
+
+func (*roundRobinSelector) Observe(string, time.Duration, error) {}
+
+// weightedSelector draws targets without replacement, weighted by
+// TargetConfig.Weight (defaulting to 1), so heavier targets are more
+// likely to end up first in the order.
+type weightedSelector struct{}
+
+func (weightedSelector) Order(targets []TargetConfig) []TargetConfig {
+	remaining := append([]TargetConfig(nil), targets...)
+	ordered := make([]TargetConfig, 0, len(targets))
+
+	for len(remaining) > 0 {
+		total := 0
+		for _, t := range remaining {
+			total += weightOf(t)
+		}
+
+		pick := rand.Intn(total)
+		idx := 0
+		for i, t := range remaining {
+			pick -= weightOf(t)
+			if pick < 0 {
+				idx = i
+				break
+			}
+		}
+
+		ordered = append(ordered, remaining[idx])
+		remaining = append(remaining[:idx], remaining[idx+1:]...)
+	}
+
+	return ordered
+}
+
+func (weightedSelector) Observe(string, time.Duration, error) {}
+
+func weightOf(t TargetConfig) int {
+	if t.Weight <= 0 {
+		return 1
+	}
+	return t.Weight
+}
+
+// p2cEWMAStats tracks an exponentially-weighted moving average of
+// latency and error rate for one target.
+type p2cEWMAStats struct {
+	latency   time.Duration
+	errorRate float64
+}
+
+// p2cEWMAAlpha weights the most recent observation against history.
+const p2cEWMAAlpha = 0.2
+
+// p2cEWMASelector implements power-of-two-choices: each call samples two
+// random candidates and prefers the one with the better recent score,
+// repeating until every candidate has a position in the order. Targets
+// never observed yet are treated as having zero latency/error rate so
+// they get an initial chance to be measured.
+type p2cEWMASelector struct {
+	mu    sync.Mutex
+	stats map[string]*p2cEWMAStats
+}
+
+func newP2CEWMASelector() *p2cEWMASelector {
+	return &p2cEWMASelector{stats: make(map[string]*p2cEWMAStats)}
+}
+
+func (s *p2cEWMASelector) Order(targets []TargetConfig) []TargetConfig {
+	remaining := append([]TargetConfig(nil), targets...)
+	ordered := make([]TargetConfig, 0, len(targets))
+
+	for len(remaining) > 1 {
+		i, j := rand.Intn(len(remaining)), rand.Intn(len(remaining))
+		if j == i {
+			j = (j + 1) % len(remaining)
+		}
+
+		best := i
+		if s.score(remaining[j]) < s.score(remaining[i]) {
+			best = j
+		}
+
+		ordered = append(ordered, remaining[best])
+		remaining = append(remaining[:best], remaining[best+1:]...)
+	}
+
+	return append(ordered, remaining...)
+}
+
+// score combines latency and error rate into a single comparable value;
+// lower is better.
+func (s *p2cEWMASelector) score(target TargetConfig) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stat, ok := s.stats[target.Name]
+	if !ok {
+		return 0
+	}
+	return float64(stat.latency) * (1 + stat.errorRate)
+}
+
+func (s *p2cEWMASelector) Observe(target string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stat, ok := s.stats[target]
+	if !ok {
+		stat = &p2cEWMAStats{latency: latency}
+		s.stats[target] = stat
+	}
+
+	errSample := 0.0
+	if err != nil {
+		errSample = 1.0
+	}
+
+	stat.latency = time.Duration(p2cEWMAAlpha*float64(latency) + (1-p2cEWMAAlpha)*float64(stat.latency))
+	stat.errorRate = p2cEWMAAlpha*errSample + (1-p2cEWMAAlpha)*stat.errorRate
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrioritySelectorKeepsConfigOrder(t *testing.T) {
+	targets := []TargetConfig{{Name: "a"}, {Name: "b"}}
+	ordered := prioritySelector{}.Order(targets)
+	assert.Equal(t, targets, ordered)
+}
+
+func TestRoundRobinSelectorRotatesStart(t *testing.T) {
+	s := &roundRobinSelector{}
+	targets := []TargetConfig{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+
+	first := s.Order(targets)
+	second := s.Order(targets)
+
+	assert.NotEqual(t, first[0].Name, second[0].Name)
+	assert.Len(t, first, 3)
+	assert.Len(t, second, 3)
+}
+
+func TestWeightedSelectorFavorsHeavierTarget(t *testing.T) {
+	s := weightedSelector{}
+	targets := []TargetConfig{
+		{Name: "heavy", Weight: 99},
+		{Name: "light", Weight: 1},
+	}
+
+	firstCounts := map[string]int{}
+	for i := 0; i < 200; i++ {
+		ordered := s.Order(targets)
+		firstCounts[ordered[0].Name]++
+	}
+
+	assert.Greater(t, firstCounts["heavy"], firstCounts["light"])
+}
+
+func TestP2CEWMASelectorRoutesMajorityToFasterTarget(t *testing.T) {
+	s := newP2CEWMASelector()
+	targets := []TargetConfig{{Name: "slow"}, {Name: "fast"}}
+
+	// Seed stats so "fast" is known to be much quicker than "slow".
+	for i := 0; i < 20; i++ {
+		s.Observe("slow", 100*time.Millisecond, nil)
+		s.Observe("fast", time.Millisecond, nil)
+	}
+
+	firstCounts := map[string]int{}
+	for i := 0; i < 200; i++ {
+		ordered := s.Order(targets)
+		firstCounts[ordered[0].Name]++
+	}
+
+	assert.Greater(t, firstCounts["fast"], firstCounts["slow"])
+}
+
+func TestHttpFailoverProxyP2CEWMARoutesToFasterBackend(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	var slowHits, fastHits int
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slowHits++
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("OK"))
+	}))
+	defer slowServer.Close()
+
+	fastServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fastHits++
+		w.Write([]byte("OK"))
+	}))
+	defer fastServer.Close()
+
+	config := createConfig()
+	config.Proxy.Strategy = "p2c-ewma"
+	config.Targets = []TargetConfig{
+		{Name: "slow", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: slowServer.URL}}},
+		{Name: "fast", Connection: TargetConfigConnection{HTTP: TargetConnectionHTTP{URL: fastServer.URL}}},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: config.Targets,
+		Config:  config.HealthChecks,
+	})
+	httpFailoverProxy := NewProxy(config, healthcheckManager)
+	handler := http.HandlerFunc(httpFailoverProxy.ServeHTTP)
+
+	for i := 0; i < 40; i++ {
+		req, err := http.NewRequest("POST", "/", bytes.NewBufferString(`{}`))
+		assert.Nil(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+	}
+
+	assert.Greater(t, fastHits, slowHits)
+}
@@ -0,0 +1,469 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/tiennampham23/rpc-gateway/internal/jsonrpc"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	// Subscription clients are expected to come from operator-controlled
+	// infrastructure behind the gateway, so we don't restrict Origin here;
+	// callers that need to can wrap WSProxy.ServeHTTP with their own check.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// WSProxy proxies WebSocket subscription traffic (eth_subscribe /
+// eth_unsubscribe) to a healthy target, transparently re-dialing and
+// re-subscribing on upstream disconnect or taint so the client's view of
+// the connection never drops.
+type WSProxy struct {
+	config      Config
+	healthcheck *HealthcheckManager
+}
+
+// NewWSProxy creates a WSProxy for the given config.
+func NewWSProxy(config Config, healthcheck *HealthcheckManager) *WSProxy {
+	return &WSProxy{
+		config:      config,
+		healthcheck: healthcheck,
+	}
+}
+
+// ServeHTTP upgrades the incoming request to a WebSocket connection and
+// services it until the client disconnects.
+func (p *WSProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	clientConn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer clientConn.Close()
+
+	session := newWSSession(p.config, p.healthcheck, clientConn)
+	session.run()
+}
+
+// subscription records an active eth_subscribe call so it can be replayed
+// against the next target after a re-dial.
+type subscription struct {
+	params             json.RawMessage
+	originalID         json.RawMessage
+	upstreamSubID      string
+	confirmed          bool
+	unsubscribePending bool
+}
+
+// wsSession manages one client connection's lifetime against a rotating
+// set of upstream targets.
+type wsSession struct {
+	config      Config
+	healthcheck *HealthcheckManager
+	client      *websocket.Conn
+
+	nextClientSubID uint64
+
+	mu                  sync.Mutex
+	cond                *sync.Cond
+	upstream            *websocket.Conn // currently active upstream, nil between dials
+	closed              bool            // run() has given up; wake any waiter for good
+	subsByClient        map[string]*subscription
+	subsByUpstream      map[string]string // upstream sub id -> client sub id
+	pendingUnsubscribes map[string]string // request id -> client sub id
+}
+
+func newWSSession(config Config, healthcheck *HealthcheckManager, client *websocket.Conn) *wsSession {
+	s := &wsSession{
+		config:              config,
+		healthcheck:         healthcheck,
+		client:              client,
+		subsByClient:        make(map[string]*subscription),
+		subsByUpstream:      make(map[string]string),
+		pendingUnsubscribes: make(map[string]string),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// run drives the client connection for its whole lifetime, re-dialing to
+// the next healthy target whenever the current upstream connection drops.
+// The client connection has a single long-lived reader for the session
+// (started here, see readClientLoop) rather than one per re-dial, since
+// gorilla/websocket forbids concurrent readers on the same connection and
+// a reader left over from a dropped upstream would otherwise race the
+// next one and could steal a client message meant for the new upstream.
+// The reader isn't started until the first upstream is live, so a client
+// message sent immediately after connecting can't be read and dropped
+// before there's anywhere to forward it. Once started, the reader blocks
+// rather than drops a message read during a later re-dial gap (see
+// waitForUpstream), so run() must wake it via shutdown on every exit path,
+// or it would block forever once no upstream is ever coming back.
+func (s *wsSession) run() {
+	defer s.shutdown()
+
+	clientDone := make(chan struct{})
+	var startReader sync.Once
+
+	excluded := make(map[string]bool)
+
+	for {
+		target, ok := s.nextTarget(excluded)
+		if !ok {
+			return
+		}
+
+		upstream, _, err := websocket.DefaultDialer.Dial(target.Connection.WS.URL, nil)
+		if err != nil {
+			excluded[target.Name] = true
+			continue
+		}
+
+		s.resubscribe(upstream)
+		s.setUpstream(upstream)
+		startReader.Do(func() { go s.readClientLoop(clientDone) })
+
+		upstreamDropped := s.readUpstream(upstream, clientDone)
+		s.setUpstream(nil)
+		upstream.Close()
+
+		if !upstreamDropped {
+			return
+		}
+		// Upstream dropped; exclude it and fall through to the next
+		// healthy target. Any eth_unsubscribe still in flight against it
+		// will never get a confirmation now, so finalize those as
+		// unsubscribed rather than resubscribing them on the next target.
+		s.dropPendingUnsubscribes()
+		excluded[target.Name] = true
+	}
+}
+
+// readClientLoop is the session's single reader for the client
+// connection, reading for as long as the client stays connected and
+// forwarding each message to whichever upstream is currently active. A
+// message that arrives in the brief gap between re-dials (no upstream set
+// yet) waits for the next upstream rather than being forwarded to a stale
+// connection or dropped - run() always ends up with a fresh upstream or
+// shuts the session down, so the wait is bounded by one or the other.
+func (s *wsSession) readClientLoop(done chan struct{}) {
+	defer close(done)
+	for {
+		_, message, err := s.client.ReadMessage()
+		if err != nil {
+			return
+		}
+		if upstream, ok := s.waitForUpstream(); ok {
+			s.forwardClientMessage(upstream, message)
+		}
+	}
+}
+
+func (s *wsSession) setUpstream(upstream *websocket.Conn) {
+	s.mu.Lock()
+	s.upstream = upstream
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// waitForUpstream blocks until an upstream connection is live, returning
+// it, or the session has shut down for good, in which case ok is false.
+func (s *wsSession) waitForUpstream() (upstream *websocket.Conn, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.upstream == nil && !s.closed {
+		s.cond.Wait()
+	}
+	return s.upstream, !s.closed
+}
+
+// shutdown wakes any reader blocked in waitForUpstream once run() has
+// given up on finding a target, so it can return instead of blocking
+// forever for an upstream that's never coming.
+func (s *wsSession) shutdown() {
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// nextTarget returns the first healthy, non-excluded target with a WS
+// connection configured.
+func (s *wsSession) nextTarget(excluded map[string]bool) (TargetConfig, bool) {
+	for _, target := range s.config.Targets {
+		if target.Connection.WS.URL == "" {
+			continue
+		}
+		if excluded[target.Name] {
+			continue
+		}
+		if !s.healthcheck.IsHealthy(target.Name) {
+			continue
+		}
+		return target, true
+	}
+	return TargetConfig{}, false
+}
+
+// resubscribe replays every subscription the gateway has recorded for
+// this client against a freshly dialed upstream connection, skipping any
+// the client has already asked to unsubscribe (its confirmation is still
+// in flight against the connection that just dropped).
+func (s *wsSession) resubscribe(upstream *websocket.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subsByUpstream = make(map[string]string)
+
+	for clientSubID, sub := range s.subsByClient {
+		if sub.unsubscribePending {
+			continue
+		}
+		req := jsonrpc.Request{
+			JSONRPC: "2.0",
+			ID:      json.RawMessage(fmt.Sprintf("%q", clientSubID)),
+			Method:  "eth_subscribe",
+			Params:  sub.params,
+		}
+		payload, err := json.Marshal(req)
+		if err != nil {
+			continue
+		}
+		upstream.WriteMessage(websocket.TextMessage, payload)
+	}
+}
+
+// dropPendingUnsubscribes finalizes any subscription whose eth_unsubscribe
+// was forwarded but never confirmed before its upstream connection dropped.
+// That confirmation's request id belonged to the dead connection and will
+// never arrive, so the client's original intent (stop this subscription)
+// is honored directly instead of resubscribing it on the next target.
+func (s *wsSession) dropPendingUnsubscribes() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for clientSubID, sub := range s.subsByClient {
+		if !sub.unsubscribePending {
+			continue
+		}
+		delete(s.subsByUpstream, sub.upstreamSubID)
+		delete(s.subsByClient, clientSubID)
+	}
+	s.pendingUnsubscribes = make(map[string]string)
+}
+
+// readUpstream reads and forwards frames from upstream, translating
+// subscription ids so the client sees a stable id across re-dials, until
+// either upstream errors or clientDone fires. It returns true if upstream
+// dropped (the caller should re-dial), or false if the client connection
+// closed first (the session should end).
+func (s *wsSession) readUpstream(upstream *websocket.Conn, clientDone <-chan struct{}) bool {
+	upstreamDone := make(chan struct{})
+
+	go func() {
+		defer close(upstreamDone)
+		for {
+			_, message, err := upstream.ReadMessage()
+			if err != nil {
+				return
+			}
+			s.forwardUpstreamMessage(message)
+		}
+	}()
+
+	select {
+	case <-clientDone:
+		return false
+	case <-upstreamDone:
+		return true
+	}
+}
+
+// forwardClientMessage forwards a frame from the client to upstream,
+// recording eth_subscribe calls under a gateway-assigned stable id and
+// translating eth_unsubscribe calls back to the current upstream id.
+func (s *wsSession) forwardClientMessage(upstream *websocket.Conn, message []byte) {
+	var req jsonrpc.Request
+	if err := json.Unmarshal(message, &req); err != nil {
+		upstream.WriteMessage(websocket.TextMessage, message)
+		return
+	}
+
+	switch req.Method {
+	case "eth_subscribe":
+		s.forwardSubscribe(upstream, req)
+	case "eth_unsubscribe":
+		s.forwardUnsubscribe(upstream, req, message)
+	default:
+		upstream.WriteMessage(websocket.TextMessage, message)
+	}
+}
+
+func (s *wsSession) forwardSubscribe(upstream *websocket.Conn, req jsonrpc.Request) {
+	clientSubID := fmt.Sprintf("gw-%d", atomic.AddUint64(&s.nextClientSubID, 1))
+
+	s.mu.Lock()
+	s.subsByClient[clientSubID] = &subscription{params: req.Params, originalID: req.ID}
+	s.mu.Unlock()
+
+	req.ID = json.RawMessage(fmt.Sprintf("%q", clientSubID))
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	upstream.WriteMessage(websocket.TextMessage, payload)
+}
+
+// forwardUnsubscribe rewrites the client-assigned subscription id (params[0])
+// to the subscription's current upstreamSubID before forwarding, since
+// upstream has no notion of the gateway's stable id, and records the call
+// so the confirmation in forwardUpstreamMessage can drop the tracked
+// subscription. It also marks the subscription unsubscribePending so
+// resubscribe() won't recreate it against a new target if the upstream
+// drops before the confirmation arrives. The client's own request id is
+// passed through unchanged, so the confirmation needs no translation
+// either. A subscription id the gateway isn't tracking is forwarded
+// unmodified.
+func (s *wsSession) forwardUnsubscribe(upstream *websocket.Conn, req jsonrpc.Request, message []byte) {
+	var params []string
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params) == 0 {
+		upstream.WriteMessage(websocket.TextMessage, message)
+		return
+	}
+
+	clientSubID := params[0]
+	sub, ok := s.subsByClientLocked(clientSubID)
+	if !ok {
+		upstream.WriteMessage(websocket.TextMessage, message)
+		return
+	}
+
+	params[0] = sub.upstreamSubID
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	req.Params = payload
+
+	s.mu.Lock()
+	sub.unsubscribePending = true
+	s.pendingUnsubscribes[string(req.ID)] = clientSubID
+	s.mu.Unlock()
+
+	out, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	upstream.WriteMessage(websocket.TextMessage, out)
+}
+
+// forwardUpstreamMessage forwards a frame from upstream to the client,
+// translating the upstream-assigned subscription id in eth_subscribe
+// confirmations and notifications back to the client's stable id.
+func (s *wsSession) forwardUpstreamMessage(message []byte) {
+	var resp struct {
+		ID     json.RawMessage `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Method string          `json:"method"`
+		Params struct {
+			Subscription string          `json:"subscription"`
+			Result       json.RawMessage `json:"result"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(message, &resp); err != nil {
+		s.client.WriteMessage(websocket.TextMessage, message)
+		return
+	}
+
+	// eth_unsubscribe confirmation: drop the tracked subscription so
+	// resubscribe() stops recreating it on the next re-dial.
+	if len(resp.ID) > 0 {
+		if clientSubID, ok := s.takePendingUnsubscribe(string(resp.ID)); ok {
+			var confirmed bool
+			json.Unmarshal(resp.Result, &confirmed)
+			s.mu.Lock()
+			if confirmed {
+				if sub, ok := s.subsByClient[clientSubID]; ok {
+					delete(s.subsByUpstream, sub.upstreamSubID)
+				}
+				delete(s.subsByClient, clientSubID)
+			} else if sub, ok := s.subsByClient[clientSubID]; ok {
+				// Upstream declined the unsubscribe; it's still an active
+				// subscription, so let resubscribe() keep replaying it.
+				sub.unsubscribePending = false
+			}
+			s.mu.Unlock()
+			s.client.WriteMessage(websocket.TextMessage, message)
+			return
+		}
+	}
+
+	// eth_subscribe confirmation: resp.ID is the client sub id we sent,
+	// resp.Result is the upstream-assigned subscription id.
+	if len(resp.ID) > 0 && len(resp.Result) > 0 {
+		clientSubID := unquote(resp.ID)
+		if sub, ok := s.subsByClientLocked(clientSubID); ok {
+			upstreamSubID := unquote(resp.Result)
+			s.mu.Lock()
+			sub.upstreamSubID = upstreamSubID
+			s.subsByUpstream[upstreamSubID] = clientSubID
+			alreadyConfirmed := sub.confirmed
+			sub.confirmed = true
+			originalID := sub.originalID
+			s.mu.Unlock()
+
+			if !alreadyConfirmed {
+				s.client.WriteMessage(websocket.TextMessage, []byte(
+					fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%q}`, string(originalID), clientSubID),
+				))
+			}
+			return
+		}
+	}
+
+	// eth_subscription notification: translate subscription id in place.
+	if resp.Method == "eth_subscription" && resp.Params.Subscription != "" {
+		s.mu.Lock()
+		clientSubID, ok := s.subsByUpstream[resp.Params.Subscription]
+		s.mu.Unlock()
+		if ok {
+			translated := fmt.Sprintf(
+				`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":%q,"result":%s}}`,
+				clientSubID, string(resp.Params.Result),
+			)
+			s.client.WriteMessage(websocket.TextMessage, []byte(translated))
+			return
+		}
+	}
+
+	s.client.WriteMessage(websocket.TextMessage, message)
+}
+
+func (s *wsSession) subsByClientLocked(clientSubID string) (*subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subsByClient[clientSubID]
+	return sub, ok
+}
+
+func (s *wsSession) takePendingUnsubscribe(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clientSubID, ok := s.pendingUnsubscribes[id]
+	if ok {
+		delete(s.pendingUnsubscribes, id)
+	}
+	return clientSubID, ok
+}
+
+func unquote(raw json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(raw, &str); err != nil {
+		return string(raw)
+	}
+	return str
+}
@@ -0,0 +1,415 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeWSBackend accepts a single subscriber, echoes back an
+// eth_subscribe confirmation, then pushes notifications it's fed over
+// notify until closed.
+func fakeWSBackend(t *testing.T, subID string, notify <-chan string) *httptest.Server {
+	t.Helper()
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if !strings.Contains(string(msg), "eth_subscribe") {
+			return
+		}
+
+		conn.WriteMessage(websocket.TextMessage, []byte(
+			`{"jsonrpc":"2.0","id":`+extractID(string(msg))+`,"result":"`+subID+`"}`,
+		))
+
+		for n := range notify {
+			conn.WriteMessage(websocket.TextMessage, []byte(
+				`{"jsonrpc":"2.0","method":"eth_subscription","params":{"subscription":"`+subID+`","result":"`+n+`"}}`,
+			))
+		}
+	}))
+}
+
+func extractID(msg string) string {
+	start := strings.Index(msg, `"id":`) + len(`"id":`)
+	end := strings.IndexByte(msg[start:], ',')
+	return msg[start : start+end]
+}
+
+func TestWSProxyReroutesOnUpstreamDisconnect(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	notify1 := make(chan string, 1)
+	backend1 := fakeWSBackend(t, "0xsub1", notify1)
+	defer backend1.Close()
+
+	notify2 := make(chan string, 1)
+	backend2 := fakeWSBackend(t, "0xsub2", notify2)
+	defer backend2.Close()
+
+	config := createConfig()
+	config.Targets = []TargetConfig{
+		{
+			Name:       "Server1",
+			Connection: TargetConfigConnection{WS: TargetConnectionWS{URL: toWS(backend1.URL)}},
+		},
+		{
+			Name:       "Server2",
+			Connection: TargetConfigConnection{WS: TargetConnectionWS{URL: toWS(backend2.URL)}},
+		},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: config.Targets,
+		Config:  config.HealthChecks,
+	})
+	wsProxy := NewWSProxy(config, healthcheckManager)
+
+	gateway := httptest.NewServer(http.HandlerFunc(wsProxy.ServeHTTP))
+	defer gateway.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial(toWS(gateway.URL), nil)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	err = client.WriteMessage(websocket.TextMessage, []byte(
+		`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`,
+	))
+	assert.Nil(t, err)
+
+	_, confirmation, err := client.ReadMessage()
+	assert.Nil(t, err)
+	assert.Contains(t, string(confirmation), `"id":1`)
+	assert.Contains(t, string(confirmation), `"result":"gw-1"`)
+
+	notify1 <- "0x1"
+	_, first, err := client.ReadMessage()
+	assert.Nil(t, err)
+	assert.Contains(t, string(first), `"subscription":"gw-1"`)
+	assert.Contains(t, string(first), `0x1`)
+
+	close(notify1)
+	backend1.Close()
+
+	notify2 <- "0x2"
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, second, err := client.ReadMessage()
+	assert.Nil(t, err)
+	assert.Contains(t, string(second), `"subscription":"gw-1"`)
+	assert.Contains(t, string(second), `0x2`)
+}
+
+func TestWSProxyUnsubscribeTranslatesIDAndStopsResubscribe(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+	received := make(chan string, 4)
+	backend1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- string(msg)
+
+			switch {
+			case strings.Contains(string(msg), "eth_subscribe"):
+				conn.WriteMessage(websocket.TextMessage, []byte(
+					`{"jsonrpc":"2.0","id":`+extractID(string(msg))+`,"result":"0xsub1"}`,
+				))
+			case strings.Contains(string(msg), "eth_unsubscribe"):
+				conn.WriteMessage(websocket.TextMessage, []byte(
+					`{"jsonrpc":"2.0","id":`+extractID(string(msg))+`,"result":true}`,
+				))
+			}
+		}
+	}))
+	defer backend1.Close()
+
+	backend2Hits := make(chan string, 4)
+	backend2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			backend2Hits <- string(msg)
+		}
+	}))
+	defer backend2.Close()
+
+	config := createConfig()
+	config.Targets = []TargetConfig{
+		{Name: "Server1", Connection: TargetConfigConnection{WS: TargetConnectionWS{URL: toWS(backend1.URL)}}},
+		{Name: "Server2", Connection: TargetConfigConnection{WS: TargetConnectionWS{URL: toWS(backend2.URL)}}},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: config.Targets,
+		Config:  config.HealthChecks,
+	})
+	wsProxy := NewWSProxy(config, healthcheckManager)
+
+	gateway := httptest.NewServer(http.HandlerFunc(wsProxy.ServeHTTP))
+	defer gateway.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial(toWS(gateway.URL), nil)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	err = client.WriteMessage(websocket.TextMessage, []byte(
+		`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`,
+	))
+	assert.Nil(t, err)
+
+	_, confirmation, err := client.ReadMessage()
+	assert.Nil(t, err)
+	assert.Contains(t, string(confirmation), `"result":"gw-1"`)
+	<-received // the eth_subscribe call upstream
+
+	err = client.WriteMessage(websocket.TextMessage, []byte(
+		`{"jsonrpc":"2.0","id":2,"method":"eth_unsubscribe","params":["gw-1"]}`,
+	))
+	assert.Nil(t, err)
+
+	sentUpstream := <-received
+	assert.Contains(t, sentUpstream, `"params":["0xsub1"]`, "unsubscribe must use the upstream subscription id, not the gateway's")
+
+	_, unsubConfirmation, err := client.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"jsonrpc":"2.0","id":2,"result":true}`, string(unsubConfirmation))
+
+	backend1.Close()
+
+	select {
+	case msg := <-backend2Hits:
+		t.Fatalf("expected no resubscribe after eth_unsubscribe, got: %s", msg)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWSProxyUnsubscribeBeforeConfirmationIsNotResubscribed(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+	received := make(chan string, 4)
+	backend1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		received <- string(msg)
+		conn.WriteMessage(websocket.TextMessage, []byte(
+			`{"jsonrpc":"2.0","id":`+extractID(string(msg))+`,"result":"0xsub1"}`,
+		))
+
+		_, msg, err = conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		received <- string(msg)
+		// Drop the connection instead of confirming the eth_unsubscribe,
+		// simulating a disconnect that races the confirmation.
+	}))
+	defer backend1.Close()
+
+	backend2Hits := make(chan string, 4)
+	backend2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			backend2Hits <- string(msg)
+		}
+	}))
+	defer backend2.Close()
+
+	config := createConfig()
+	config.Targets = []TargetConfig{
+		{Name: "Server1", Connection: TargetConfigConnection{WS: TargetConnectionWS{URL: toWS(backend1.URL)}}},
+		{Name: "Server2", Connection: TargetConfigConnection{WS: TargetConnectionWS{URL: toWS(backend2.URL)}}},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: config.Targets,
+		Config:  config.HealthChecks,
+	})
+	wsProxy := NewWSProxy(config, healthcheckManager)
+
+	gateway := httptest.NewServer(http.HandlerFunc(wsProxy.ServeHTTP))
+	defer gateway.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial(toWS(gateway.URL), nil)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	err = client.WriteMessage(websocket.TextMessage, []byte(
+		`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`,
+	))
+	assert.Nil(t, err)
+
+	_, confirmation, err := client.ReadMessage()
+	assert.Nil(t, err)
+	assert.Contains(t, string(confirmation), `"result":"gw-1"`)
+	<-received // the eth_subscribe call upstream
+
+	err = client.WriteMessage(websocket.TextMessage, []byte(
+		`{"jsonrpc":"2.0","id":2,"method":"eth_unsubscribe","params":["gw-1"]}`,
+	))
+	assert.Nil(t, err)
+	<-received // the eth_unsubscribe call upstream, never confirmed
+
+	// backend1 drops the connection (no confirmation ever arrives) instead
+	// of us closing it ourselves, so wait for the proxy to notice.
+	select {
+	case msg := <-backend2Hits:
+		t.Fatalf("expected no resubscribe after an in-flight eth_unsubscribe, got: %s", msg)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestWSProxyQueuesClientMessageDuringRedialGap(t *testing.T) {
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+	backend1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			conn.Close()
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(
+			`{"jsonrpc":"2.0","id":`+extractID(string(msg))+`,"result":"0xsub1"}`,
+		))
+		// Drop right after confirming, forcing run() into a re-dial.
+		conn.Close()
+	}))
+	defer backend1.Close()
+
+	received := make(chan string, 4)
+	backend2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Widen the re-dial gap so a client message sent right after
+		// backend1 drops lands while the session still has no upstream.
+		time.Sleep(150 * time.Millisecond)
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}))
+	defer backend2.Close()
+
+	config := createConfig()
+	config.Targets = []TargetConfig{
+		{Name: "Server1", Connection: TargetConfigConnection{WS: TargetConnectionWS{URL: toWS(backend1.URL)}}},
+		{Name: "Server2", Connection: TargetConfigConnection{WS: TargetConnectionWS{URL: toWS(backend2.URL)}}},
+	}
+
+	healthcheckManager := NewHealthcheckManager(HealthcheckManagerConfig{
+		Targets: config.Targets,
+		Config:  config.HealthChecks,
+	})
+	wsProxy := NewWSProxy(config, healthcheckManager)
+
+	gateway := httptest.NewServer(http.HandlerFunc(wsProxy.ServeHTTP))
+	defer gateway.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial(toWS(gateway.URL), nil)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	err = client.WriteMessage(websocket.TextMessage, []byte(
+		`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`,
+	))
+	assert.Nil(t, err)
+
+	_, confirmation, err := client.ReadMessage()
+	assert.Nil(t, err)
+	assert.Contains(t, string(confirmation), `"result":"gw-1"`)
+
+	// Sent while backend1 is dropping and backend2's handshake is still
+	// pending - must be queued and forwarded once backend2 comes up, not
+	// silently dropped.
+	err = client.WriteMessage(websocket.TextMessage, []byte(
+		`{"jsonrpc":"2.0","id":2,"method":"eth_subscribe","params":["logs"]}`,
+	))
+	assert.Nil(t, err)
+
+	select {
+	case msg := <-received:
+		assert.Contains(t, msg, `"id":"gw-1"`, "expected the replayed newHeads subscription first")
+		assert.Contains(t, msg, `"params":["newHeads"]`)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for resubscribe against backend2")
+	}
+
+	select {
+	case msg := <-received:
+		assert.Contains(t, msg, `"method":"eth_subscribe"`)
+		assert.Contains(t, msg, `"params":["logs"]`)
+	case <-time.After(2 * time.Second):
+		t.Fatal("client message sent during the re-dial gap was dropped instead of queued")
+	}
+}
+
+func toWS(url string) string {
+	return "ws" + strings.TrimPrefix(url, "http")
+}